@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// buildahBuilder shells out to `buildah bud` for rootless use, mirroring the
+// podman ContainerRuntime backend.
+type buildahBuilder struct{}
+
+func (b *buildahBuilder) Build(p *Project) (string, error) {
+	dir := buildContextDir(p)
+	tag := p.ImageRepository + ":" + p.Tag
+
+	iidFile, err := os.CreateTemp("", "buildah-iid-")
+	if err != nil {
+		return "", err
+	}
+	iidFile.Close()
+	defer os.Remove(iidFile.Name())
+
+	cmd := exec.Command("buildah", "bud", "--tag", tag, "--iidfile", iidFile.Name(), dir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("buildah bud failed: %v", err)
+	}
+
+	id, err := os.ReadFile(iidFile.Name())
+	if err != nil {
+		return "", err
+	}
+	return normalizeImageId(strings.TrimSpace(string(id))), nil
+}
+
+// normalizeImageId ensures id carries an algorithm prefix like the Docker
+// daemon's build-stream aux.ID always does, since buildah's --iidfile isn't
+// guaranteed to include one and extractImageId expects "algo:digest".
+func normalizeImageId(id string) string {
+	if !strings.Contains(id, ":") {
+		return "sha256:" + id
+	}
+	return id
+}
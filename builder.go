@@ -0,0 +1,27 @@
+package main
+
+// Builder builds p.ImageRepository:p.Tag from the Dockerfile/Containerfile
+// under buildContextDir(p), returning the resulting image ID so setImageId
+// can cache it onto p.Image for the subsequent createContainer(false).
+type Builder interface {
+	Build(p *Project) (string, error)
+}
+
+// newBuilder picks the Builder matching the selected ContainerRuntime: the
+// Docker daemon's own ImageBuild API, or `buildah bud` for rootless use.
+func newBuilder(engine string) Builder {
+	switch engine {
+	case "podman":
+		return &buildahBuilder{}
+	default:
+		return &dockerBuilder{}
+	}
+}
+
+// buildContextDir is --build when given, otherwise BaseDir itself.
+func buildContextDir(p *Project) string {
+	if len(p.Build) != 0 {
+		return p.Build
+	}
+	return p.BaseDir
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,43 +15,44 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
-	"github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/archive"
-	flags "github.com/jessevdk/go-flags"
 )
 
 var rand uint32
 
+// Options holds the flags accepted by the `create` subcommand; cli.go binds
+// these to cobra flags and finalizeOptions fills in the computed defaults.
 type Options struct {
-	BaseDir      string `short:"b" long:"basedir" description:"base source directory" required:"true" group:"required"`
-	Cmd          string `short:"c" long:"cmd" description:"CMD for container" default:"tail -f /dev/null"`
-	Cname        string `long:"cname" description:"container name"`
-	Gid          int    `long:"gid " description:"GID in container"`
-	Gname        string `long:"gname" description:"group name" default:"deployer"`
-	ImageId      string `long:"imageid" description:"docker image id"`
-	InstallJson  string `short:"j" long:"json" description:"install_json.json" required:"true" group:"required"`
-	NoproxyHosts string `long:"noproxy" description:"no proxy hosts" default:"localhost,127.0.0.1"`
-	Privilege    bool   `long:"priviledge" description:"run container in priviledged mode"`
-	Root         bool   `long:"root" description:"run container as root"`
-	Tag          string `long:"tag" description:"image tag" default:"latest"`
-	Uid          int    `long:"uid" description:"UID in container"`
-	Uname        string `long:"uname" description:"user name" default:"deployer"`
-	Project      string `short:"p" long:"project" description:"project type" required:"true" group:"required" choice:"higgs" choice:"konrad" choice:"racdb"`
-	Workdir      string `short:"w" long:"workdir" description:"working directory"`
-	DockerApi    string `long:"apiversion" description:"docker client api version" default:"1.24"`
+	BaseDir      string
+	Cmd          string
+	Cname        string
+	Gid          int
+	Gname        string
+	ImageId      string
+	InstallJson  string
+	NoproxyHosts string
+	Privilege    bool
+	Root         bool
+	Tag          string
+	Uid          int
+	Uname        string
+	Project      string
+	Workdir      string
+	DockerApi    string
+	Engine       string
+	Build        string
+	Pod          string
+	PodSpec      string
 }
 
 type Project struct {
 	Options
-	DockerClient struct {
-		Ctx    *context.Context
-		Client *client.Client
-	}
+	Runtime         ContainerRuntime
 	ImageRepository string
 	Image           types.ImageSummary
 	Container       container.ContainerCreateCreatedBody // set in createContainer()
 	Shell           string                               // set in checkShell()
 	SourceDir       string                               // set in setSourceDir()
+	PodInfraId      string                               // set by Pod.createInfra() when running in pod mode
 }
 
 var project = [3]string{"konrad", "higgs", "racdb"}
@@ -103,17 +103,9 @@ func check(e error, msg string) {
 	}
 }
 
-func optParser() *Options {
-	var opts Options
-
-	if _, err := flags.Parse(&opts); err != nil {
-		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
-			os.Exit(0)
-		} else {
-			os.Exit(1)
-		}
-	}
-
+// finalizeOptions fills in the defaults that depend on other flags or the
+// environment, once cobra has parsed the create subcommand's flags into opts.
+func finalizeOptions(opts *Options) *Options {
 	if len(opts.Cname) == 0 {
 		t := time.Now()
 		opts.Cname = "deployer_" + t.Format("Jan02Mon3456")
@@ -138,16 +130,11 @@ func optParser() *Options {
 	opts.BaseDir, _ = filepath.Abs(opts.BaseDir)
 	opts.InstallJson, _ = filepath.Abs(opts.InstallJson)
 
-	return &opts
+	return opts
 }
 
 // --- Tools ---
 
-func extractClient(p *Project) (cli *client.Client, ctx *context.Context) {
-	cli = p.DockerClient.Client
-	ctx = p.DockerClient.Ctx
-	return
-}
 func getUserInfo(t int) interface{} {
 	user, err := user.Current()
 
@@ -202,32 +189,12 @@ func extractImageId(id string) string {
 }
 
 func (p *Project) copyFileFromContainer(from, to string, hook func() string) {
-	cli, ctx := extractClient(p)
-	fromIo, stat, err := cli.CopyFromContainer(*ctx, p.Container.ID, from)
+	err := p.Runtime.CopyFileFromContainer(p.Container.ID, from, to, hook)
 	check(err, "Copy file "+from+" from container "+p.Container.ID+" failed.")
-
-	srcInfo := archive.CopyInfo{
-		Path:       from,
-		Exists:     true,
-		IsDir:      stat.Mode.IsDir(),
-		RebaseName: "",
-	}
-	// untar and copy
-	archive.CopyTo(fromIo, srcInfo, to)
-	// write uid/gid info
-	fd, err := os.OpenFile(to, os.O_APPEND|os.O_WRONLY, 0644)
-	fd.WriteString(hook())
-	defer fromIo.Close()
-	defer fd.Close()
 }
 
 func (p *Project) inspectContainerFile(path string) bool {
-	cli, ctx := extractClient(p)
-	_, err := cli.ContainerStatPath(*ctx, p.Container.ID, path)
-	if err != nil {
-		return false
-	}
-	return true
+	return p.Runtime.InspectContainerFile(p.Container.ID, path)
 }
 
 func (p *Project) checkShell() {
@@ -264,20 +231,12 @@ func (p *Project) setSourceDir() {
 }
 
 func (p *Project) printUsage() {
-	execCmd := "docker exec -it %s %s"
-	stopCmd := "docker stop %s"
-	rmCmd := "docker rm %s"
-	lsCmd := "docker ps -a"
-	stopallCmd := `docker stop $(docker ps -a -q)`
-	rmallCmd := `docker rm $(docker ps -a -q)`
 	logLoc := filepath.Join(p.Workdir, "log")
 	srcLoc := p.SourceDir
-	fmt.Println("Access container: " + fmt.Sprintf(execCmd, p.Container.ID[:12], shell[p.Shell]))
-	fmt.Println("Stop container: " + fmt.Sprintf(stopCmd, p.Container.ID[:12]))
-	fmt.Println("Remove container: " + fmt.Sprintf(rmCmd, p.Container.ID[:12]))
-	fmt.Println("List all containers: " + lsCmd)
-	fmt.Println("Stop all containers: " + stopallCmd)
-	fmt.Println("Remove all containers: " + rmallCmd)
+	fmt.Println("Access container: create-container exec " + p.Cname + " -- " + shell[p.Shell])
+	fmt.Println("Stop container: create-container stop " + p.Cname)
+	fmt.Println("Remove container: create-container rm " + p.Cname)
+	fmt.Println("List all containers: create-container ls")
 	fmt.Println("Log location: " + logLoc)
 	fmt.Println("Source location: " + srcLoc)
 }
@@ -285,42 +244,43 @@ func (p *Project) printUsage() {
 // ---End of tools---
 
 func (p *Project) createDockerClient() {
-	// setup docker client api
+	// setup docker client api (unused by the podman engine)
 	os.Setenv("DOCKER_API_VERSION", p.DockerApi)
 
-	ctx := context.Background()
-	cli, err := client.NewEnvClient()
-	check(err, "Create docker client error.")
-
-	p.DockerClient.Ctx = &ctx
-	p.DockerClient.Client = cli
+	p.Runtime = newContainerRuntime(p.Engine)
+	check(p.Runtime.Connect(), "Create "+p.Engine+" client error.")
 }
 
 func (p *Project) setImageId() {
-	cli, ctx := extractClient(p)
-	ilist, err := cli.ImageList(*ctx, types.ImageListOptions{})
-	check(err, "Get docker image list error.")
-
-	for _, image := range ilist {
-		// if docker image id provided, honor image id
-		if strings.Contains(image.ID, p.ImageRepository) {
-			p.Image = image
-			return
-		}
-		for _, name := range image.RepoTags {
-			tagTmp := strings.Split(name, ":")
-			if strings.Contains(tagTmp[0], p.ImageRepository) {
-				if strings.Contains(tagTmp[1], p.Tag) {
-					p.Image = image
+	ilist, err := p.Runtime.ListImages()
+	check(err, "Get "+p.Engine+" image list error.")
+
+	if len(p.Build) == 0 {
+		for _, image := range ilist {
+			// if docker image id provided, honor image id
+			if strings.Contains(image.ID, p.ImageRepository) {
+				p.Image = image
+				return
+			}
+			for _, name := range image.RepoTags {
+				tagTmp := strings.Split(name, ":")
+				if strings.Contains(tagTmp[0], p.ImageRepository) {
+					if strings.Contains(tagTmp[1], p.Tag) {
+						p.Image = image
+					}
 				}
 			}
 		}
-	}
-	defer func() {
-		if p.Image.ID == "" {
-			panic("No proper Image found in this host.")
+		if p.Image.ID != "" {
+			return
 		}
-	}()
+	}
+
+	// No matching image on this host (or --build was requested): build it
+	// from a Dockerfile/Containerfile instead of giving up.
+	imageId, err := newBuilder(p.Engine).Build(p)
+	check(err, "Build image for "+p.ImageRepository+":"+p.Tag+" failed.")
+	p.Image = types.ImageSummary{ID: imageId, RepoTags: []string{p.ImageRepository + ":" + p.Tag}}
 }
 
 func (p *Project) prepareHostConfig(init bool) *container.HostConfig {
@@ -349,45 +309,62 @@ func (p *Project) prepareHostConfig(init bool) *container.HostConfig {
 	srcMount.BindOptions = &bindOptions
 	srcbind := fmt.Sprintf("%s:%s", srcMount.Source, srcMount.Target)
 
+	hostMount, hostbind := p.hostBindMount()
+
+	hostConfig.Binds = []string{logbind, srcbind, hostbind}
+	hostConfig.Mounts = []mount.Mount{logMount, srcMount, hostMount}
+
+	// Rootless podman maps the container user via subuid/subgid and
+	// --userns=keep-id, so it doesn't need the passwd/group binds below.
+	if p.Engine != "podman" {
+		var passwdMount mount.Mount
+		passwdMount.Type = mount.TypeBind
+		passwdMount.Source = filepath.Join(p.Workdir, "passwd")
+		passwdMount.Target = pgfiles["passwd"]
+		passwdMount.ReadOnly = false
+		passwdMount.BindOptions = &bindOptions
+		passwdbind := fmt.Sprintf("%s:%s", passwdMount.Source, passwdMount.Target)
+
+		var groupMount mount.Mount
+		groupMount.Type = mount.TypeBind
+		groupMount.Source = filepath.Join(p.Workdir, "group")
+		groupMount.Target = pgfiles["group"]
+		groupMount.ReadOnly = false
+		groupMount.BindOptions = &bindOptions
+		groupbind := fmt.Sprintf("%s:%s", groupMount.Source, groupMount.Target)
+
+		hostConfig.Binds = append(hostConfig.Binds, passwdbind, groupbind)
+		hostConfig.Mounts = append(hostConfig.Mounts, passwdMount, groupMount)
+	}
+
+	hostConfig.AutoRemove = true
+	hostConfig.Privileged = p.Privilege
+
+	// Inside a Pod, the primary deployer shares the infra container's
+	// network and IPC namespaces instead of getting its own.
+	if len(p.PodInfraId) != 0 {
+		hostConfig.NetworkMode = container.NetworkMode("container:" + p.PodInfraId)
+		hostConfig.IpcMode = container.IpcMode("container:" + p.PodInfraId)
+	}
+
+	return &hostConfig
+}
+
+// hostBindMount is the /home/shinto/host bind every container in a Pod
+// shares, so sidecars see the same source and log directories as the
+// primary deployer.
+func (p *Project) hostBindMount() (mount.Mount, string) {
+	var bindOptions mount.BindOptions
+	bindOptions.Propagation = mount.PropagationRPrivate
+
 	var hostMount mount.Mount
 	hostMount.Type = mount.TypeBind
 	hostMount.Source = p.Workdir
 	hostMount.Target = `/home/shinto/host`
 	hostMount.ReadOnly = false
 	hostMount.BindOptions = &bindOptions
-	hostbind := fmt.Sprintf("%s:%s", hostMount.Source, hostMount.Target)
-
-	var passwdMount mount.Mount
-	passwdMount.Type = mount.TypeBind
-	passwdMount.Source = filepath.Join(p.Workdir, "passwd")
-	passwdMount.Target = pgfiles["passwd"]
-	passwdMount.ReadOnly = false
-	passwdMount.BindOptions = &bindOptions
-	passwdbind := fmt.Sprintf("%s:%s", passwdMount.Source, passwdMount.Target)
-
-	var groupMount mount.Mount
-	groupMount.Type = mount.TypeBind
-	groupMount.Source = filepath.Join(p.Workdir, "group")
-	groupMount.Target = pgfiles["group"]
-	groupMount.ReadOnly = false
-	groupMount.BindOptions = &bindOptions
-	groupbind := fmt.Sprintf("%s:%s", groupMount.Source, groupMount.Target)
-
-	hostConfig.Binds = []string{
-		logbind,
-		srcbind,
-		hostbind,
-		passwdbind,
-		groupbind}
-	hostConfig.AutoRemove = true
-	hostConfig.Privileged = p.Privilege
-	hostConfig.Mounts = []mount.Mount{
-		logMount,
-		srcMount,
-		hostMount,
-		passwdMount,
-		passwdMount}
-	return &hostConfig
+
+	return hostMount, fmt.Sprintf("%s:%s", hostMount.Source, hostMount.Target)
 }
 
 // Prepare Config for creating the container
@@ -410,29 +387,28 @@ func (p *Project) prepareConfig(init bool) *container.Config {
 	envStr = append(envStr, "no_proxy="+p.NoproxyHosts)
 	config.Env = envStr
 
+	// tag containers we create so ls/prune/exec can find them later without
+	// the user copy-pasting IDs
+	config.Labels = map[string]string{managedByLabel: managedByValue}
+
 	return &config
 }
 
 func (p *Project) createContainer(init bool) {
-	cli, ctx := extractClient(p)
 	config := p.prepareConfig(init)
 	hostConfig := p.prepareHostConfig(init)
-	body, err := cli.ContainerCreate(*ctx, config, hostConfig, nil, p.Cname)
+	body, err := p.Runtime.CreateContainer(config, hostConfig, p.Cname)
 	check(err, "Create container failed.")
 	p.Container = body
 }
 
 func (p *Project) removeContainer() {
-	cli, ctx := extractClient(p)
-	var removeConfig types.ContainerRemoveOptions = types.ContainerRemoveOptions{false, false, true}
-	err := cli.ContainerRemove(*ctx, p.Container.ID, removeConfig)
+	err := p.Runtime.RemoveContainer(p.Container.ID)
 	check(err, "Remove container: "+p.Container.ID+" failed")
 }
 
 func (p *Project) startContainer() {
-	cli, ctx := extractClient(p)
-	options := types.ContainerStartOptions{}
-	err := cli.ContainerStart(*ctx, p.Container.ID, options)
+	err := p.Runtime.StartContainer(p.Container.ID)
 	check(err, "Start container failed.")
 }
 
@@ -445,6 +421,12 @@ func (p *Project) createWorkDir() {
 }
 
 func (p *Project) rewriteUidGid() {
+	if p.Engine == "podman" {
+		// Rootless podman already maps the container user via subuid/subgid
+		// (--userns=keep-id), so there's no /etc/passwd or /etc/group to patch.
+		return
+	}
+
 	hooks := map[string]func() string{
 		"passwd": func() string {
 			//"{user}:x:{uid}:{gid}::{home}:{shell}"
@@ -541,10 +523,3 @@ func (p *Project) run() {
 	p.startContainer()
 	p.printUsage()
 }
-
-func main() {
-	var opts = optParser()
-	var project Project
-	project.prepare(opts)
-	project.run()
-}
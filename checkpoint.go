@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (p *Project) checkpointDir(name string) string {
+	return filepath.Join(p.Workdir, "checkpoints", name)
+}
+
+// tcpEstablishedState is the /proc/net/tcp "st" code for an ESTABLISHED socket
+// (see linux/include/net/tcp_states.h); LISTEN (0A) and the other states
+// aren't open connections in CRIU's --tcp-established sense.
+const tcpEstablishedState = "01"
+
+// hasEstablishedTCPConnection reports whether procNetTCP (the contents of a
+// container's /proc/net/tcp) has an ESTABLISHED entry, so checkpointContainer
+// only refuses to checkpoint on real open connections, not e.g. a listening
+// sshd.
+func hasEstablishedTCPConnection(procNetTCP []byte) bool {
+	lines := strings.Split(strings.TrimSpace(string(procNetTCP)), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > 3 && fields[3] == tcpEstablishedState {
+			return true
+		}
+	}
+	return false
+}
+
+// checkpointContainer snapshots the running deployer's memory/filesystem
+// state via CRIU, so a long-running deploy test can be paused across host
+// reboots and picked back up with restoreContainer.
+func (p *Project) checkpointContainer(name string, opts CheckpointOptions) {
+	if !opts.TCPEstablished {
+		open, err := p.Runtime.HasOpenTCPConnections(p.Container.ID)
+		check(err, "Check open TCP connections in "+p.Container.ID+" failed.")
+		if open {
+			panic("Container " + p.Container.ID + " has open TCP connections; pass --tcp-established to checkpoint anyway.")
+		}
+	}
+
+	dir := p.checkpointDir(name)
+	check(os.MkdirAll(dir, os.FileMode(0755)), "Create checkpoint dir "+dir+" failed.")
+	check(p.Runtime.CheckpointContainer(p.Container.ID, name, dir, opts), "Checkpoint container "+p.Container.ID+" failed.")
+}
+
+// restoreContainer re-creates the container with the same prepareConfig/
+// prepareHostConfig (so bind mounts stay identical) and starts it from the
+// named checkpoint instead of from scratch.
+func (p *Project) restoreContainer(name string) {
+	p.createContainer(false)
+	check(p.Runtime.StartContainerFromCheckpoint(p.Container.ID, name, p.checkpointDir(name)),
+		"Restore container from checkpoint "+name+" failed.")
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestHasEstablishedTCPConnection(t *testing.T) {
+	tests := []struct {
+		name string
+		proc string
+		want bool
+	}{
+		{
+			name: "only the header line",
+			proc: "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n",
+			want: false,
+		},
+		{
+			name: "a listening socket",
+			proc: "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+				"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0\n",
+			want: false,
+		},
+		{
+			name: "an established connection",
+			proc: "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+				"   0: 0100007F:1F90 0100007F:9C40 01 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 20 4 30 10 -1\n",
+			want: true,
+		},
+		{
+			name: "a listener alongside an established connection",
+			proc: "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n" +
+				"   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0\n" +
+				"   1: 0100007F:1F90 0100007F:9C40 01 00000000:00000000 00:00000000 00000000     0        0 12346 1 0000000000000000 20 4 30 10 -1\n",
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasEstablishedTCPConnection([]byte(tt.proc)); got != tt.want {
+				t.Errorf("hasEstablishedTCPConnection(%q) = %v, want %v", tt.proc, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "create-container",
+	Short: "Spin up and manage deployer containers",
+}
+
+// Execute runs the cobra command tree; it's the program's sole entrypoint.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(createCmd, stopCmd, rmCmd, execCmd, logsCmd, lsCmd, pruneCmd, existsCmd, generateCmd)
+	rootCmd.SetUsageTemplate(usageTemplate)
+}
+
+// usageTemplate groups management vs operation subcommands the way the
+// Docker CLI's own cobra setup does.
+const usageTemplate = `Usage:
+  {{.CommandPath}} [command]
+
+Management Commands:
+  ls          List containers create-container has created
+  prune       Remove stopped containers create-container has created
+
+Operation Commands:
+  create      Create and start a new deployer container
+  exec        Run a command in a running container
+  generate    Generate deployment artifacts from a would-be container
+  logs        Fetch the logs of a container
+  rm          Remove a container
+  stop        Stop a running container
+  exists      Check whether a container still exists
+  checkpoint  Checkpoint a running container to disk via CRIU
+  restore     Restore a container from a named checkpoint
+
+Use "{{.CommandPath}} [command] --help" for more information about a command.
+`
+
+var createOpts Options
+
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create and start a new deployer container",
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := finalizeOptions(&createOpts)
+
+		var project Project
+		project.prepare(opts)
+
+		var pod *Pod
+		if len(opts.Pod) != 0 {
+			project.createDockerClient()
+			spec, err := loadPodSpec(opts.PodSpec)
+			check(err, "Load pod spec "+opts.PodSpec+" failed.")
+			pod = NewPod(opts.Pod, &project, spec)
+			pod.run()
+		} else {
+			project.run()
+		}
+
+		record := ContainerRecord{
+			ID:           project.Container.ID,
+			Name:         project.Cname,
+			Project:      project.Project,
+			Workdir:      project.Workdir,
+			Image:        project.Image.ID,
+			Engine:       project.Engine,
+			CreatedAt:    time.Now(),
+			SourceDir:    project.SourceDir,
+			Cmd:          project.Cmd,
+			NoproxyHosts: project.NoproxyHosts,
+			Root:         project.Root,
+			Privilege:    project.Privilege,
+		}
+		check(addRecord(record), "Persist container state failed.")
+
+		if pod != nil {
+			check(addRecord(ContainerRecord{
+				ID:        pod.InfraId,
+				Name:      pod.Name + "_infra",
+				Engine:    project.Engine,
+				CreatedAt: time.Now(),
+				Workdir:   project.Workdir,
+			}), "Persist pod infra state failed.")
+
+			for i, svc := range pod.Services {
+				check(addRecord(ContainerRecord{
+					ID:        pod.Sidecars[i].ID,
+					Name:      pod.Name + "_" + svc.Name,
+					Engine:    project.Engine,
+					CreatedAt: time.Now(),
+					Workdir:   project.Workdir,
+				}), "Persist pod sidecar state failed.")
+			}
+		}
+	},
+}
+
+// addContainerFlags binds the flags shared by every subcommand that builds a
+// Project (create, generate kube, ...) onto opts.
+func addContainerFlags(f *pflag.FlagSet, opts *Options) {
+	f.StringVarP(&opts.BaseDir, "basedir", "b", "", "base source directory")
+	f.StringVarP(&opts.Cmd, "cmd", "c", "tail -f /dev/null", "CMD for container")
+	f.StringVar(&opts.Cname, "cname", "", "container name")
+	f.IntVar(&opts.Gid, "gid", 0, "GID in container")
+	f.StringVar(&opts.Gname, "gname", "deployer", "group name")
+	f.StringVar(&opts.ImageId, "imageid", "", "docker image id")
+	f.StringVarP(&opts.InstallJson, "json", "j", "", "install_json.json")
+	f.StringVar(&opts.NoproxyHosts, "noproxy", "localhost,127.0.0.1", "no proxy hosts")
+	f.BoolVar(&opts.Privilege, "priviledge", false, "run container in priviledged mode")
+	f.BoolVar(&opts.Root, "root", false, "run container as root")
+	f.StringVar(&opts.Tag, "tag", "latest", "image tag")
+	f.IntVar(&opts.Uid, "uid", 0, "UID in container")
+	f.StringVar(&opts.Uname, "uname", "deployer", "user name")
+	f.StringVarP(&opts.Project, "project", "p", "", "project type (higgs, konrad, racdb)")
+	f.StringVarP(&opts.Workdir, "workdir", "w", "", "working directory")
+	f.StringVar(&opts.DockerApi, "apiversion", "1.24", "docker client api version")
+	f.StringVar(&opts.Engine, "engine", "docker", "container engine to use (docker, podman)")
+	f.StringVar(&opts.Build, "build", "", "build the image from this Dockerfile/Containerfile directory instead of requiring it to already exist")
+	f.StringVar(&opts.Pod, "pod", "", "run as a pod: name to give the shared infra container, with sidecars from --podspec")
+	f.StringVar(&opts.PodSpec, "podspec", "pod.yaml", "pod.yaml describing the sidecar services to attach, used with --pod")
+}
+
+func init() {
+	addContainerFlags(createCmd.Flags(), &createOpts)
+	createCmd.MarkFlagRequired("basedir")
+	createCmd.MarkFlagRequired("json")
+	createCmd.MarkFlagRequired("project")
+}
+
+var stopCmd = &cobra.Command{
+	Use:   "stop <name>",
+	Short: "Stop a running container",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		check(err, "Stop container failed.")
+
+		rt := newContainerRuntime(r.Engine)
+		check(rt.Connect(), "Create "+r.Engine+" client error.")
+		check(rt.StopContainer(r.ID), "Stop container "+r.Name+" failed.")
+	},
+}
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Short: "Remove a container",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		check(err, "Remove container failed.")
+
+		rt := newContainerRuntime(r.Engine)
+		check(rt.Connect(), "Create "+r.Engine+" client error.")
+		check(rt.RemoveContainer(r.ID), "Remove container "+r.Name+" failed.")
+		check(removeRecord(r.ID), "Persist container state failed.")
+	},
+}
+
+var execCmd = &cobra.Command{
+	Use:   "exec <name> -- <cmd...>",
+	Short: "Run a command in a running container",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		check(err, "Exec into container failed.")
+
+		rt := newContainerRuntime(r.Engine)
+		check(rt.Connect(), "Create "+r.Engine+" client error.")
+		check(rt.ExecContainer(r.ID, args[1:]), "Exec in container "+r.Name+" failed.")
+	},
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <name>",
+	Short: "Fetch the logs of a container",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		check(err, "Fetch logs failed.")
+
+		rt := newContainerRuntime(r.Engine)
+		check(rt.Connect(), "Create "+r.Engine+" client error.")
+		out, err := rt.ContainerLogs(r.ID)
+		check(err, "Fetch logs for "+r.Name+" failed.")
+		defer out.Close()
+		io.Copy(os.Stdout, out)
+	},
+}
+
+var existsCmd = &cobra.Command{
+	Use:   "exists <name>",
+	Short: "Check whether a container still exists",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		if err != nil {
+			fmt.Println("false")
+			os.Exit(1)
+		}
+
+		rt := newContainerRuntime(r.Engine)
+		check(rt.Connect(), "Create "+r.Engine+" client error.")
+		if !rt.ContainerExists(r.ID) {
+			fmt.Println("false")
+			os.Exit(1)
+		}
+		fmt.Println("true")
+	},
+}
+
+var lsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List containers create-container has created",
+	Run: func(cmd *cobra.Command, args []string) {
+		records, err := loadState()
+		check(err, "Read container state failed.")
+
+		fmt.Printf("%-30s %-12s %-12s %-8s %s\n", "NAME", "PROJECT", "ID", "ENGINE", "CREATED")
+		for _, r := range records {
+			fmt.Printf("%-30s %-12s %-12s %-8s %s\n", r.Name, r.Project, shortId(r.ID), r.Engine, r.CreatedAt.Format(time.RFC3339))
+		}
+	},
+}
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove stopped containers create-container has created",
+	Run: func(cmd *cobra.Command, args []string) {
+		records, err := loadState()
+		check(err, "Read container state failed.")
+
+		var kept []ContainerRecord
+		for _, r := range records {
+			rt := newContainerRuntime(r.Engine)
+			if err := rt.Connect(); err != nil || rt.ContainerExists(r.ID) {
+				kept = append(kept, r)
+				continue
+			}
+			fmt.Println("Pruned " + r.Name + " (" + shortId(r.ID) + ")")
+		}
+		check(saveState(kept), "Persist container state failed.")
+	},
+}
+
+func shortId(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate deployment artifacts from a would-be container",
+}
+
+var generateKubeOpts Options
+var kubeOutput string
+
+var generateKubeCmd = &cobra.Command{
+	Use:   "kube",
+	Short: "Generate a Kubernetes Pod manifest for the deployer container",
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := finalizeOptions(&generateKubeOpts)
+
+		var project Project
+		project.prepare(opts)
+		project.createDockerClient()
+		project.setImageId()
+		project.setSourceDir()
+		project.createWorkDir()
+
+		manifest, err := project.generateKube()
+		check(err, "Generate kube manifest failed.")
+
+		if len(kubeOutput) == 0 {
+			fmt.Print(string(manifest))
+			return
+		}
+		check(os.WriteFile(kubeOutput, manifest, 0644), "Write kube manifest failed.")
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(generateKubeCmd)
+
+	addContainerFlags(generateKubeCmd.Flags(), &generateKubeOpts)
+	generateKubeCmd.Flags().StringVarP(&kubeOutput, "output", "o", "", "write manifest to file instead of stdout")
+
+	generateKubeCmd.MarkFlagRequired("basedir")
+	generateKubeCmd.MarkFlagRequired("json")
+	generateKubeCmd.MarkFlagRequired("project")
+}
+
+var tcpEstablished bool
+var leaveRunning bool
+
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint <name> <checkpoint>",
+	Short: "Checkpoint a running container to disk via CRIU",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		check(err, "Checkpoint container failed.")
+
+		var project Project
+		project.Cname = r.Name
+		project.Workdir = r.Workdir
+		project.Engine = r.Engine
+		project.Container.ID = r.ID
+
+		project.Runtime = newContainerRuntime(r.Engine)
+		check(project.Runtime.Connect(), "Create "+r.Engine+" client error.")
+
+		project.checkpointContainer(args[1], CheckpointOptions{
+			TCPEstablished: tcpEstablished,
+			LeaveRunning:   leaveRunning,
+		})
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <name> <checkpoint>",
+	Short: "Restore a container from a named checkpoint",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		r, err := findRecord(args[0])
+		check(err, "Restore container failed.")
+
+		var project Project
+		project.Cname = r.Name
+		project.Project = r.Project
+		project.Workdir = r.Workdir
+		project.SourceDir = r.SourceDir
+		project.Cmd = r.Cmd
+		project.NoproxyHosts = r.NoproxyHosts
+		project.Root = r.Root
+		project.Privilege = r.Privilege
+		project.Engine = r.Engine
+		project.Image = types.ImageSummary{ID: r.Image}
+
+		project.Runtime = newContainerRuntime(r.Engine)
+		check(project.Runtime.Connect(), "Create "+r.Engine+" client error.")
+
+		project.restoreContainer(args[1])
+
+		check(removeRecord(r.ID), "Persist container state failed.")
+		check(addRecord(ContainerRecord{
+			ID:           project.Container.ID,
+			Name:         project.Cname,
+			Project:      project.Project,
+			Workdir:      project.Workdir,
+			Image:        project.Image.ID,
+			Engine:       project.Engine,
+			CreatedAt:    time.Now(),
+			SourceDir:    project.SourceDir,
+			Cmd:          project.Cmd,
+			NoproxyHosts: project.NoproxyHosts,
+			Root:         project.Root,
+			Privilege:    project.Privilege,
+		}), "Persist container state failed.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkpointCmd, restoreCmd)
+
+	checkpointCmd.Flags().BoolVar(&tcpEstablished, "tcp-established", false, "checkpoint a container even if it has established TCP connections")
+	checkpointCmd.Flags().BoolVar(&leaveRunning, "leave-running", false, "leave the container running after checkpointing")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// dockerBuilder drives the Docker Engine's ImageBuild API, streaming the
+// build context as a tar so users see the same output docker build gives.
+type dockerBuilder struct{}
+
+func (b *dockerBuilder) Build(p *Project) (string, error) {
+	dir := buildContextDir(p)
+
+	buildCtx, err := archive.TarWithOptions(dir, &archive.TarOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer buildCtx.Close()
+
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := cli.ImageBuild(context.Background(), buildCtx, types.ImageBuildOptions{
+		Tags: []string{p.ImageRepository + ":" + p.Tag},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var imageId string
+	aux := func(msg jsonmessage.JSONMessage) {
+		var result types.BuildResult
+		if json.Unmarshal(*msg.Aux, &result) == nil {
+			imageId = result.ID
+		}
+	}
+	if err := jsonmessage.DisplayJSONMessagesStream(resp.Body, os.Stdout, os.Stdout.Fd(), false, aux); err != nil {
+		return "", err
+	}
+	if len(imageId) == 0 {
+		return "", fmt.Errorf("build of %s did not report an image ID", dir)
+	}
+	return imageId, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+)
+
+// dockerRuntime talks to a Docker Engine daemon via the standard API client.
+// It's the ContainerRuntime used when --engine=docker (the default).
+type dockerRuntime struct {
+	ctx context.Context
+	cli *client.Client
+}
+
+func (r *dockerRuntime) Connect() error {
+	r.ctx = context.Background()
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	r.cli = cli
+	return nil
+}
+
+func (r *dockerRuntime) ListImages() ([]types.ImageSummary, error) {
+	return r.cli.ImageList(r.ctx, types.ImageListOptions{})
+}
+
+func (r *dockerRuntime) CreateContainer(config *container.Config, hostConfig *container.HostConfig, cname string) (container.ContainerCreateCreatedBody, error) {
+	return r.cli.ContainerCreate(r.ctx, config, hostConfig, nil, nil, cname)
+}
+
+func (r *dockerRuntime) StartContainer(id string) error {
+	return r.cli.ContainerStart(r.ctx, id, types.ContainerStartOptions{})
+}
+
+func (r *dockerRuntime) RemoveContainer(id string) error {
+	removeConfig := types.ContainerRemoveOptions{Force: true}
+	return r.cli.ContainerRemove(r.ctx, id, removeConfig)
+}
+
+func (r *dockerRuntime) CopyFileFromContainer(id, from, to string, hook func() string) error {
+	fromIo, stat, err := r.cli.CopyFromContainer(r.ctx, id, from)
+	if err != nil {
+		return err
+	}
+	defer fromIo.Close()
+
+	srcInfo := archive.CopyInfo{
+		Path:       from,
+		Exists:     true,
+		IsDir:      stat.Mode.IsDir(),
+		RebaseName: "",
+	}
+	// untar and copy
+	archive.CopyTo(fromIo, srcInfo, to)
+
+	// write uid/gid info
+	fd, err := os.OpenFile(to, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	fd.WriteString(hook())
+	return nil
+}
+
+func (r *dockerRuntime) InspectContainerFile(id, path string) bool {
+	_, err := r.cli.ContainerStatPath(r.ctx, id, path)
+	return err == nil
+}
+
+func (r *dockerRuntime) StopContainer(id string) error {
+	return r.cli.ContainerStop(r.ctx, id, nil)
+}
+
+func (r *dockerRuntime) ContainerExists(id string) bool {
+	_, err := r.cli.ContainerInspect(r.ctx, id)
+	return err == nil
+}
+
+func (r *dockerRuntime) ContainerLogs(id string) (io.ReadCloser, error) {
+	return r.cli.ContainerLogs(r.ctx, id, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+}
+
+func (r *dockerRuntime) ExecContainer(id string, cmd []string) error {
+	execConfig := types.ExecConfig{Cmd: cmd, AttachStdout: true, AttachStderr: true, Tty: true}
+	created, err := r.cli.ContainerExecCreate(r.ctx, id, execConfig)
+	if err != nil {
+		return err
+	}
+	attach, err := r.cli.ContainerExecAttach(r.ctx, created.ID, types.ExecStartCheck{Tty: true})
+	if err != nil {
+		return err
+	}
+	defer attach.Close()
+	_, err = io.Copy(os.Stdout, attach.Reader)
+	return err
+}
+
+// HasOpenTCPConnections peeks at /proc/net/tcp inside the container's network
+// namespace for an ESTABLISHED entry; listening sockets don't count.
+func (r *dockerRuntime) HasOpenTCPConnections(id string) (bool, error) {
+	inspect, err := r.cli.ContainerInspect(r.ctx, id)
+	if err != nil {
+		return false, err
+	}
+	out, err := exec.Command("nsenter", "-t", strconv.Itoa(inspect.State.Pid), "-n", "cat", "/proc/net/tcp").Output()
+	if err != nil {
+		return false, err
+	}
+	return hasEstablishedTCPConnection(out), nil
+}
+
+func (r *dockerRuntime) CheckpointContainer(id, name, checkpointDir string, opts CheckpointOptions) error {
+	return r.cli.CheckpointCreate(r.ctx, id, types.CheckpointCreateOptions{
+		CheckpointID:  name,
+		CheckpointDir: checkpointDir,
+		Exit:          !opts.LeaveRunning,
+	})
+}
+
+func (r *dockerRuntime) StartContainerFromCheckpoint(id, name, checkpointDir string) error {
+	return r.cli.ContainerStart(r.ctx, id, types.ContainerStartOptions{
+		CheckpointID:  name,
+		CheckpointDir: checkpointDir,
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// generateKube serializes the container.Config/HostConfig that createContainer
+// would use into a v1.Pod manifest, mirroring podman's "generate kube" idea so
+// the deployer can be handed to `kubectl apply -f` instead of a local daemon.
+func (p *Project) generateKube() ([]byte, error) {
+	config := p.prepareConfig(false)
+	hostConfig := p.prepareHostConfig(false)
+
+	var volumes []v1.Volume
+	var mounts []v1.VolumeMount
+	for i, m := range hostConfig.Mounts {
+		volName := fmt.Sprintf("bind-%d", i)
+		volumes = append(volumes, v1.Volume{
+			Name: volName,
+			VolumeSource: v1.VolumeSource{
+				HostPath: &v1.HostPathVolumeSource{Path: m.Source},
+			},
+		})
+		mounts = append(mounts, v1.VolumeMount{Name: volName, MountPath: m.Target})
+	}
+
+	privileged := hostConfig.Privileged
+	runAsUser := int64(p.Uid)
+	runAsGroup := int64(p.Gid)
+
+	pod := v1.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   p.Cname,
+			Labels: config.Labels,
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{
+				{
+					Name:    p.Cname,
+					Image:   p.ImageRepository + ":" + p.Tag,
+					Command: config.Entrypoint,
+					Env:     kubeEnvVars(config.Env),
+					SecurityContext: &v1.SecurityContext{
+						Privileged: &privileged,
+						RunAsUser:  &runAsUser,
+						RunAsGroup: &runAsGroup,
+					},
+					VolumeMounts: mounts,
+				},
+			},
+			Volumes: volumes,
+		},
+	}
+
+	return yaml.Marshal(&pod)
+}
+
+// kubeEnvVars turns the "KEY=VALUE" strings prepareConfig builds into the
+// v1.EnvVar pairs a Pod spec expects.
+func kubeEnvVars(env []string) []v1.EnvVar {
+	var vars []v1.EnvVar
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			continue
+		}
+		vars = append(vars, v1.EnvVar{Name: kv[0], Value: kv[1]})
+	}
+	return vars
+}
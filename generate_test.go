@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestKubeEnvVars(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		want []v1.EnvVar
+	}{
+		{
+			name: "basic pairs",
+			env:  []string{"C_FORCE_ROOT=1", "no_proxy=localhost,127.0.0.1"},
+			want: []v1.EnvVar{
+				{Name: "C_FORCE_ROOT", Value: "1"},
+				{Name: "no_proxy", Value: "localhost,127.0.0.1"},
+			},
+		},
+		{
+			name: "value containing an equals sign",
+			env:  []string{"FOO=bar=baz"},
+			want: []v1.EnvVar{{Name: "FOO", Value: "bar=baz"}},
+		},
+		{
+			name: "malformed entries are skipped",
+			env:  []string{"no_equals_sign", "=novalue", "OK=1"},
+			want: []v1.EnvVar{{Name: "OK", Value: "1"}},
+		},
+		{
+			name: "empty input",
+			env:  nil,
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := kubeEnvVars(tt.env)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kubeEnvVars(%v) = %+v, want %+v", tt.env, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"sigs.k8s.io/yaml"
+)
+
+// infraImage is the pause container every Pod creates first, purely to hold
+// the network and IPC namespaces the primary deployer and its sidecars share.
+const infraImage = "registry.k8s.io/pause:3.9"
+
+// PodService describes one sidecar container attached to a Pod, as loaded
+// from pod.yaml.
+type PodService struct {
+	Name  string            `json:"name"`
+	Image string            `json:"image"`
+	Cmd   string            `json:"cmd"`
+	Env   map[string]string `json:"env,omitempty"`
+}
+
+// PodSpec is the top-level shape of pod.yaml.
+type PodSpec struct {
+	Services []PodService `json:"services"`
+}
+
+func loadPodSpec(path string) (*PodSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec PodSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// Pod drives the primary deployer's Project through a shared network/IPC
+// namespace with its sidecars, mirroring podman's generate-kube pod model:
+// an infra/pause container is created first, then every other container
+// joins it via HostConfig.NetworkMode/IpcMode = "container:<infra-id>".
+type Pod struct {
+	Name     string
+	Primary  *Project
+	Services []PodService
+	InfraId  string
+	Sidecars []container.ContainerCreateCreatedBody
+}
+
+func NewPod(name string, primary *Project, spec *PodSpec) *Pod {
+	return &Pod{Name: name, Primary: primary, Services: spec.Services}
+}
+
+// createInfra starts the pause container and records its ID on both the Pod
+// and the primary Project, so prepareHostConfig knows to join it instead of
+// creating its own network namespace.
+func (pod *Pod) createInfra() {
+	p := pod.Primary
+	config := &container.Config{Image: infraImage}
+	hostConfig := &container.HostConfig{AutoRemove: true}
+	body, err := p.Runtime.CreateContainer(config, hostConfig, pod.Name+"_infra")
+	check(err, "Create pod infra container failed.")
+	check(p.Runtime.StartContainer(body.ID), "Start pod infra container failed.")
+	pod.InfraId = body.ID
+	p.PodInfraId = body.ID
+}
+
+// attachSidecar starts one pod.yaml service alongside the primary deployer,
+// sharing its network/IPC namespaces and its /home/shinto/host bind so the
+// sidecar sees the same source and log directories.
+func (pod *Pod) attachSidecar(svc PodService) {
+	p := pod.Primary
+
+	var envStr []string
+	for k, v := range svc.Env {
+		envStr = append(envStr, k+"="+v)
+	}
+	config := &container.Config{Image: svc.Image, Env: envStr}
+	if len(svc.Cmd) != 0 {
+		config.Entrypoint = strings.Split(svc.Cmd, " ")
+	}
+
+	hostMount, hostbind := p.hostBindMount()
+	hostConfig := &container.HostConfig{
+		Binds:       []string{hostbind},
+		Mounts:      []mount.Mount{hostMount},
+		NetworkMode: container.NetworkMode("container:" + pod.InfraId),
+		IpcMode:     container.IpcMode("container:" + pod.InfraId),
+		AutoRemove:  true,
+	}
+
+	body, err := p.Runtime.CreateContainer(config, hostConfig, pod.Name+"_"+svc.Name)
+	check(err, "Create pod sidecar "+svc.Name+" failed.")
+	check(p.Runtime.StartContainer(body.ID), "Start pod sidecar "+svc.Name+" failed.")
+	pod.Sidecars = append(pod.Sidecars, body)
+}
+
+// run is Project.run()'s pod-mode counterpart: infra container first, then
+// the primary deployer (which now joins the infra's namespaces), then every
+// pod.yaml sidecar.
+func (pod *Pod) run() {
+	pod.createInfra()
+
+	p := pod.Primary
+	p.setImageId()
+	p.setSourceDir()
+	p.createWorkDir()
+	p.createContainer(true)
+	p.checkShell()
+	p.rewriteUidGid()
+	p.removeContainer()
+	p.touchRepoconfigJson()
+	p.copyInstallJson()
+	p.createContainer(false)
+	p.startContainer()
+
+	for _, svc := range pod.Services {
+		pod.attachSidecar(svc)
+	}
+
+	p.printUsage()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadPodSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pod.yaml")
+	contents := `
+services:
+  - name: artifactory
+    image: mock-artifactory:latest
+    cmd: /start.sh
+    env:
+      PORT: "8081"
+`
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+
+	spec, err := loadPodSpec(path)
+	if err != nil {
+		t.Fatalf("loadPodSpec: %v", err)
+	}
+
+	want := &PodSpec{Services: []PodService{
+		{Name: "artifactory", Image: "mock-artifactory:latest", Cmd: "/start.sh", Env: map[string]string{"PORT": "8081"}},
+	}}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("loadPodSpec(%q) = %+v, want %+v", path, spec, want)
+	}
+}
+
+func TestLoadPodSpecMissingFile(t *testing.T) {
+	if _, err := loadPodSpec(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("loadPodSpec on a missing file: expected error, got nil")
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// podmanRuntime talks to a rootless Podman REST API over its user socket
+// (unix:///run/user/$UID/podman/podman.sock), so developers without access
+// to a Docker daemon can still spin up deployer containers. It uses the
+// Docker-compatible endpoints for image listing, start/remove/copy/stat,
+// and the libpod-specific create endpoint to request keep-id userns mapping.
+type podmanRuntime struct {
+	sockPath string
+	http     *http.Client
+}
+
+func podmanSockPath() string {
+	return fmt.Sprintf("/run/user/%d/podman/podman.sock", getUserInfo(uid).(int))
+}
+
+func (r *podmanRuntime) Connect() error {
+	r.sockPath = podmanSockPath()
+	if _, err := os.Stat(r.sockPath); err != nil {
+		return fmt.Errorf("podman socket %s not found: %v", r.sockPath, err)
+	}
+	r.http = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", r.sockPath)
+			},
+		},
+	}
+	return nil
+}
+
+// podmanError is the {"cause", "message", "response"} body podman's REST API
+// returns on a 4xx/5xx response.
+type podmanError struct {
+	Cause    string `json:"cause"`
+	Message  string `json:"message"`
+	Response int    `json:"response"`
+}
+
+func (r *podmanRuntime) do(method, path string, body interface{}) (*http.Response, error) {
+	var reqBody *bytes.Buffer = &bytes.Buffer{}
+	if body != nil {
+		if err := json.NewEncoder(reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, "http://d"+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var podErr podmanError
+		if json.NewDecoder(resp.Body).Decode(&podErr) == nil && len(podErr.Message) != 0 {
+			return nil, fmt.Errorf("podman %s %s: %s", method, path, podErr.Message)
+		}
+		return nil, fmt.Errorf("podman %s %s: unexpected status %s", method, path, resp.Status)
+	}
+	return resp, nil
+}
+
+func (r *podmanRuntime) ListImages() ([]types.ImageSummary, error) {
+	resp, err := r.do("GET", "/v1.40/images/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var images []types.ImageSummary
+	err = json.NewDecoder(resp.Body).Decode(&images)
+	return images, err
+}
+
+// keepIDCreateRequest is the subset of libpod's SpecGenerator needed to map
+// a Docker container.Config/HostConfig onto a rootless, keep-id container.
+type keepIDCreateRequest struct {
+	Image      string            `json:"image"`
+	Command    []string          `json:"command,omitempty"`
+	Env        map[string]string `json:"env,omitempty"`
+	User       string            `json:"user,omitempty"`
+	Privileged bool              `json:"privileged,omitempty"`
+	Mounts     []mountSpec       `json:"mounts,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Userns     userns            `json:"userns,omitempty"`
+	Netns      *namespaceSpec    `json:"netns,omitempty"`
+	Ipcns      *namespaceSpec    `json:"ipcns,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+type mountSpec struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Type        string `json:"type"`
+}
+
+// envMap converts the "KEY=VALUE" strings container.Config.Env uses into the
+// map libpod's SpecGenerator expects.
+func envMap(env []string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(env))
+	for _, e := range env {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || len(kv[0]) == 0 {
+			continue
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m
+}
+
+// userns mirrors libpod's specgen.Namespace for --userns=keep-id semantics:
+// the container user is created via subuid/subgid mapping instead of a
+// bind-mounted /etc/passwd and /etc/group.
+type userns struct {
+	NSMode string `json:"nsmode"`
+}
+
+// namespaceSpec mirrors libpod's specgen.Namespace for the netns/ipcns of a
+// Pod's sidecar: nsmode "container" plus the infra container's ID joins its
+// namespace the same way HostConfig.NetworkMode/IpcMode = "container:<id>"
+// does for the Docker backend.
+type namespaceSpec struct {
+	NSMode string `json:"nsmode"`
+	Value  string `json:"value,omitempty"`
+}
+
+// containerNamespace builds a namespaceSpec for a Docker NetworkMode/IpcMode
+// string of the form "container:<id>", or nil if mode doesn't join another
+// container's namespace.
+func containerNamespace(mode string) *namespaceSpec {
+	parts := strings.SplitN(mode, ":", 2)
+	if len(parts) != 2 || parts[0] != "container" {
+		return nil
+	}
+	return &namespaceSpec{NSMode: "container", Value: parts[1]}
+}
+
+func (r *podmanRuntime) CreateContainer(config *container.Config, hostConfig *container.HostConfig, cname string) (container.ContainerCreateCreatedBody, error) {
+	req := keepIDCreateRequest{
+		Image:      config.Image,
+		Command:    config.Entrypoint,
+		Env:        envMap(config.Env),
+		User:       config.User,
+		Privileged: hostConfig != nil && hostConfig.Privileged,
+		Name:       cname,
+		Userns:     userns{NSMode: "keep-id"},
+		Labels:     config.Labels,
+	}
+	if hostConfig != nil {
+		for _, m := range hostConfig.Mounts {
+			req.Mounts = append(req.Mounts, mountSpec{Source: m.Source, Destination: m.Target, Type: "bind"})
+		}
+		req.Netns = containerNamespace(string(hostConfig.NetworkMode))
+		req.Ipcns = containerNamespace(string(hostConfig.IpcMode))
+	}
+
+	resp, err := r.do("POST", "/v4.0.0/libpod/containers/create", req)
+	if err != nil {
+		return container.ContainerCreateCreatedBody{}, err
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		Id string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return container.ContainerCreateCreatedBody{}, err
+	}
+	return container.ContainerCreateCreatedBody{ID: created.Id}, nil
+}
+
+func (r *podmanRuntime) StartContainer(id string) error {
+	_, err := r.do("POST", "/v1.40/containers/"+id+"/start", nil)
+	return err
+}
+
+func (r *podmanRuntime) RemoveContainer(id string) error {
+	_, err := r.do("DELETE", "/v1.40/containers/"+id+"?force=true", nil)
+	return err
+}
+
+func (r *podmanRuntime) CopyFileFromContainer(id, from, to string, hook func() string) error {
+	resp, err := r.do("GET", "/v1.40/containers/"+id+"/archive?path="+from, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	fd, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	if _, err := fd.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	fd.WriteString(hook())
+	return nil
+}
+
+func (r *podmanRuntime) InspectContainerFile(id, path string) bool {
+	resp, err := r.do("GET", "/v1.40/containers/"+id+"/archive?path="+path, nil)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func (r *podmanRuntime) StopContainer(id string) error {
+	_, err := r.do("POST", "/v1.40/containers/"+id+"/stop", nil)
+	return err
+}
+
+func (r *podmanRuntime) ContainerExists(id string) bool {
+	resp, err := r.do("GET", "/v1.40/containers/"+id+"/json", nil)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func (r *podmanRuntime) ContainerLogs(id string) (io.ReadCloser, error) {
+	resp, err := r.do("GET", "/v1.40/containers/"+id+"/logs?stdout=true&stderr=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (r *podmanRuntime) ExecContainer(id string, cmd []string) error {
+	execReq := struct {
+		Cmd          []string `json:"Cmd"`
+		AttachStdout bool     `json:"AttachStdout"`
+		AttachStderr bool     `json:"AttachStderr"`
+		Tty          bool     `json:"Tty"`
+	}{Cmd: cmd, AttachStdout: true, AttachStderr: true, Tty: true}
+
+	resp, err := r.do("POST", "/v1.40/containers/"+id+"/exec", execReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		Id string `json:"Id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return err
+	}
+
+	start, err := r.do("POST", "/v1.40/exec/"+created.Id+"/start", map[string]bool{"Tty": true})
+	if err != nil {
+		return err
+	}
+	defer start.Body.Close()
+	_, err = io.Copy(os.Stdout, start.Body)
+	return err
+}
+
+func (r *podmanRuntime) HasOpenTCPConnections(id string) (bool, error) {
+	resp, err := r.do("GET", "/v1.40/containers/"+id+"/json", nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var inspect struct {
+		State struct {
+			Pid int
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command("nsenter", "-t", strconv.Itoa(inspect.State.Pid), "-n", "cat", "/proc/net/tcp").Output()
+	if err != nil {
+		return false, err
+	}
+	return hasEstablishedTCPConnection(out), nil
+}
+
+// CheckpointContainer uses libpod's checkpoint endpoint, exporting the CRIU
+// image to checkpointDir/name.tar.gz.
+func (r *podmanRuntime) CheckpointContainer(id, name, checkpointDir string, opts CheckpointOptions) error {
+	export := filepath.Join(checkpointDir, name+".tar.gz")
+	path := fmt.Sprintf("/v4.0.0/libpod/containers/%s/checkpoint?export=%s&leaveRunning=%t&tcpEstablished=%t",
+		id, export, opts.LeaveRunning, opts.TCPEstablished)
+	_, err := r.do("POST", path, nil)
+	return err
+}
+
+func (r *podmanRuntime) StartContainerFromCheckpoint(id, name, checkpointDir string) error {
+	export := filepath.Join(checkpointDir, name+".tar.gz")
+	path := fmt.Sprintf("/v4.0.0/libpod/containers/%s/restore?import=%s", id, export)
+	_, err := r.do("POST", path, nil)
+	return err
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContainerNamespace(t *testing.T) {
+	tests := []struct {
+		mode string
+		want *namespaceSpec
+	}{
+		{mode: "container:abc123", want: &namespaceSpec{NSMode: "container", Value: "abc123"}},
+		{mode: "", want: nil},
+		{mode: "bridge", want: nil},
+		{mode: "host", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if got := containerNamespace(tt.mode); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("containerNamespace(%q) = %+v, want %+v", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvMap(t *testing.T) {
+	got := envMap([]string{"C_FORCE_ROOT=1", "no_proxy=localhost,127.0.0.1", "malformed", "=skip"})
+	want := map[string]string{"C_FORCE_ROOT": "1", "no_proxy": "localhost,127.0.0.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envMap(...) = %+v, want %+v", got, want)
+	}
+	if got := envMap(nil); got != nil {
+		t.Errorf("envMap(nil) = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// ContainerRuntime abstracts the backend used to create, start, inspect and
+// tear down the deployer container, so the rest of Project doesn't care
+// whether it's talking to a Docker daemon or a rootless Podman socket.
+type ContainerRuntime interface {
+	// Connect establishes the client connection to the backend.
+	Connect() error
+	// ListImages returns the images known to the backend, used by setImageId
+	// to resolve p.ImageRepository/p.Tag to a concrete image.
+	ListImages() ([]types.ImageSummary, error)
+	CreateContainer(config *container.Config, hostConfig *container.HostConfig, cname string) (container.ContainerCreateCreatedBody, error)
+	StartContainer(id string) error
+	StopContainer(id string) error
+	RemoveContainer(id string) error
+	CopyFileFromContainer(id, from, to string, hook func() string) error
+	InspectContainerFile(id, path string) bool
+	// ContainerExists reports whether id still exists on the backend, used by
+	// ls/prune/exists to reconcile the on-disk state file with reality.
+	ContainerExists(id string) bool
+	ContainerLogs(id string) (io.ReadCloser, error)
+	ExecContainer(id string, cmd []string) error
+
+	// HasOpenTCPConnections reports whether id currently holds established
+	// TCP connections, so checkpointContainer can refuse to snapshot it
+	// unless --tcp-established was passed.
+	HasOpenTCPConnections(id string) (bool, error)
+	CheckpointContainer(id, name, checkpointDir string, opts CheckpointOptions) error
+	StartContainerFromCheckpoint(id, name, checkpointDir string) error
+}
+
+// CheckpointOptions mirrors CRIU's --tcp-established/--leave-running flags.
+type CheckpointOptions struct {
+	TCPEstablished bool
+	LeaveRunning   bool
+}
+
+// newContainerRuntime builds the ContainerRuntime selected by --engine.
+func newContainerRuntime(engine string) ContainerRuntime {
+	switch engine {
+	case "", "docker":
+		return &dockerRuntime{}
+	case "podman":
+		return &podmanRuntime{}
+	default:
+		panic("Unknown container engine: " + engine)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// managedByLabel/managedByValue tag every container this tool creates, so
+// ls/prune/exec/stop/rm/logs/exists can find it again without the user
+// copy-pasting container IDs.
+const managedByLabel = "deployer.oracle/managed-by"
+const managedByValue = "create_container"
+
+// ContainerRecord is what gets persisted to the state file for each
+// container create-container has created. The fields beyond the basics
+// (project, workdir, image, timestamps) let restoreContainer rebuild the
+// same container.Config/HostConfig without the user re-typing every flag.
+type ContainerRecord struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Project      string    `json:"project"`
+	Workdir      string    `json:"workdir"`
+	Image        string    `json:"image"`
+	Engine       string    `json:"engine"`
+	CreatedAt    time.Time `json:"created_at"`
+	SourceDir    string    `json:"source_dir"`
+	Cmd          string    `json:"cmd"`
+	NoproxyHosts string    `json:"noproxy_hosts"`
+	Root         bool      `json:"root"`
+	Privilege    bool      `json:"privilege"`
+}
+
+func stateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); len(dir) != 0 {
+		return filepath.Join(dir, "create_container")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "create_container")
+}
+
+func stateFile() string {
+	return filepath.Join(stateDir(), "containers.json")
+}
+
+func loadState() ([]ContainerRecord, error) {
+	data, err := os.ReadFile(stateFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var records []ContainerRecord
+	err = json.Unmarshal(data, &records)
+	return records, err
+}
+
+func saveState(records []ContainerRecord) error {
+	if err := os.MkdirAll(stateDir(), os.FileMode(0755)); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile(), data, 0644)
+}
+
+func addRecord(r ContainerRecord) error {
+	records, err := loadState()
+	if err != nil {
+		return err
+	}
+	records = append(records, r)
+	return saveState(records)
+}
+
+func removeRecord(id string) error {
+	records, err := loadState()
+	if err != nil {
+		return err
+	}
+	kept := records[:0]
+	for _, r := range records {
+		if r.ID != id {
+			kept = append(kept, r)
+		}
+	}
+	return saveState(kept)
+}
+
+// findRecord resolves a name or (possibly abbreviated) container ID to the
+// managed container it refers to.
+func findRecord(nameOrId string) (*ContainerRecord, error) {
+	records, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Name == nameOrId || r.ID == nameOrId || strings.HasPrefix(r.ID, nameOrId) {
+			return &r, nil
+		}
+	}
+	return nil, fmt.Errorf("no managed container matching %q", nameOrId)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAddFindRemoveRecord(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	r1 := ContainerRecord{ID: "abcdef123456", Name: "deployer_one"}
+	r2 := ContainerRecord{ID: "fedcba654321", Name: "deployer_two"}
+
+	if err := addRecord(r1); err != nil {
+		t.Fatalf("addRecord(r1): %v", err)
+	}
+	if err := addRecord(r2); err != nil {
+		t.Fatalf("addRecord(r2): %v", err)
+	}
+
+	if got, err := findRecord("deployer_two"); err != nil || got.ID != r2.ID {
+		t.Fatalf("findRecord by name = %+v, %v; want %+v", got, err, r2)
+	}
+	if got, err := findRecord("abcdef123456"); err != nil || got.Name != r1.Name {
+		t.Fatalf("findRecord by full ID = %+v, %v; want %+v", got, err, r1)
+	}
+	if got, err := findRecord("abcdef"); err != nil || got.Name != r1.Name {
+		t.Fatalf("findRecord by abbreviated ID = %+v, %v; want %+v", got, err, r1)
+	}
+	if _, err := findRecord("no-such-container"); err == nil {
+		t.Fatal("findRecord with unknown name: expected error, got nil")
+	}
+
+	if err := removeRecord(r1.ID); err != nil {
+		t.Fatalf("removeRecord(r1): %v", err)
+	}
+	if _, err := findRecord(r1.Name); err == nil {
+		t.Fatal("findRecord after removal: expected error, got nil")
+	}
+	if got, err := findRecord(r2.Name); err != nil || got.ID != r2.ID {
+		t.Fatalf("findRecord(r2) after removing r1 = %+v, %v; want %+v", got, err, r2)
+	}
+}
+
+func TestLoadStateEmpty(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	records, err := loadState()
+	if err != nil {
+		t.Fatalf("loadState on empty state dir: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("loadState on empty state dir = %+v, want empty", records)
+	}
+}